@@ -0,0 +1,10 @@
+package config
+
+import "os"
+
+// SecretKey is the shared secret gating POST /api/auth/token. Leave unset
+// (the default) to keep JWT authentication disabled entirely, since minting
+// tokens would otherwise be impossible. Configurable via FLET_SECRET_KEY.
+func SecretKey() string {
+	return os.Getenv("FLET_SECRET_KEY")
+}
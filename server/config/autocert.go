@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// AutocertCache, when set, backs the autocert certificate cache with a
+// custom store (e.g. Google Cloud Storage, S3, or Redis) instead of the
+// local filesystem. Leave nil to fall back to autocert.DirCache using
+// AutocertCacheDir().
+var AutocertCache autocert.Cache
+
+// AutocertHosts returns the domain allowlist fed into autocert.HostWhitelist.
+// It is parsed from the comma-separated FLET_AUTOCERT_HOSTS env variable.
+// An empty result means autocert/TLS is disabled.
+func AutocertHosts() []string {
+	v := os.Getenv("FLET_AUTOCERT_HOSTS")
+	if v == "" {
+		return nil
+	}
+	hosts := make([]string, 0)
+	for _, h := range strings.Split(v, ",") {
+		h = strings.TrimSpace(h)
+		if h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// AutocertCacheDir returns the directory used by autocert.DirCache to
+// persist issued certificates when AutocertCache is not set.
+func AutocertCacheDir() string {
+	if v := os.Getenv("FLET_AUTOCERT_CACHE_DIR"); v != "" {
+		return v
+	}
+	return "autocert-cache"
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// CompressionLevel returns the gzip/brotli compression level used by the
+// response compression middleware (see server.wrapCompression). gziphandler
+// accepts -1 (default) or 1..9; any other value makes wrapCompression log an
+// error and serve responses uncompressed. Configurable via
+// FLET_COMPRESSION_LEVEL.
+func CompressionLevel() int {
+	if v := os.Getenv("FLET_COMPRESSION_LEVEL"); v != "" {
+		if level, err := strconv.Atoi(v); err == nil {
+			return level
+		}
+	}
+	return 5
+}
+
+// CompressionMinSize returns the minimum response size, in bytes, below
+// which the compression middleware leaves a response uncompressed (so small
+// JSON API responses aren't wrapped). Configurable via
+// FLET_COMPRESSION_MIN_SIZE.
+func CompressionMinSize() int {
+	if v := os.Getenv("FLET_COMPRESSION_MIN_SIZE"); v != "" {
+		if size, err := strconv.Atoi(v); err == nil {
+			return size
+		}
+	}
+	return 1400
+}
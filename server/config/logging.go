@@ -0,0 +1,33 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// AccessLogFormat selects how accessLogMiddleware renders each request
+// event: "json" (default), "text", or "off" to disable access logging
+// entirely. Configurable via FLET_ACCESS_LOG_FORMAT.
+func AccessLogFormat() string {
+	switch os.Getenv("FLET_ACCESS_LOG_FORMAT") {
+	case "text":
+		return "text"
+	case "off":
+		return "off"
+	default:
+		return "json"
+	}
+}
+
+// AccessLogSampleRate returns the fraction of requests (0.0-1.0) that get an
+// access log event, for high-QPS deployments that don't want to log every
+// request. Defaults to 1 (log everything). Configurable via
+// FLET_ACCESS_LOG_SAMPLE_RATE.
+func AccessLogSampleRate() float64 {
+	if v := os.Getenv("FLET_ACCESS_LOG_SAMPLE_RATE"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err == nil && rate >= 0 && rate <= 1 {
+			return rate
+		}
+	}
+	return 1
+}
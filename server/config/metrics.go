@@ -0,0 +1,11 @@
+package config
+
+import "os"
+
+// MetricsEnabled reports whether GET /api/metrics should expose Prometheus
+// metrics (index cache hit/miss counters and friends). Off by default so
+// deployments don't expose internals unless they opt in. Configurable via
+// FLET_METRICS_ENABLED.
+func MetricsEnabled() bool {
+	return os.Getenv("FLET_METRICS_ENABLED") == "true"
+}
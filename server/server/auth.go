@@ -0,0 +1,162 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flet-dev/flet/server/config"
+)
+
+const tokenTTL = 24 * time.Hour
+
+// PagePayload is the JWT claim set minted by the /api/auth/token endpoint
+// and checked by verifyToken (authRequired for /api/upload and
+// /api/oauth/redirect, wsAuthRequired for /ws).
+type PagePayload struct {
+	jwt.Payload
+	PageName    string   `json:"page_name"`
+	SessionID   string   `json:"session_id"`
+	Permissions []string `json:"permissions"`
+}
+
+func signer() *jwt.HMACSHA {
+	return jwt.NewHS256([]byte(config.SecretKey()))
+}
+
+// mintPageToken signs a PagePayload scoped to pageName, expiring after
+// tokenTTL. Only the /api/auth/token handler (requireSharedSecret) may call
+// this - everything downstream only ever verifies tokens.
+func mintPageToken(pageName string, sessionID string, permissions []string) (string, error) {
+	now := time.Now()
+	payload := PagePayload{
+		Payload: jwt.Payload{
+			Issuer:         "flet-server",
+			IssuedAt:       jwt.NumericDate(now),
+			NotBefore:      jwt.NumericDate(now),
+			ExpirationTime: jwt.NumericDate(now.Add(tokenTTL)),
+		},
+		PageName:    pageName,
+		SessionID:   sessionID,
+		Permissions: permissions,
+	}
+
+	token, err := jwt.Sign(payload, signer())
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+// bearerToken reads the token from the Authorization header, falling back to
+// a ?token= query parameter since browsers can't set headers on a WebSocket
+// handshake.
+func bearerToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return c.Query("token")
+}
+
+// authRequired verifies the PagePayload JWT on /api/upload and
+// /api/oauth/redirect. It's a no-op when FLET_SECRET_KEY isn't configured,
+// keeping local/dev setups working without tokens.
+func authRequired() gin.HandlerFunc {
+	return verifyToken(false)
+}
+
+// wsAuthRequired verifies the PagePayload JWT on /ws. Unlike authRequired,
+// the page match is mandatory rather than best-effort: /ws carries no page
+// segment in its path the way the index/NoRoute handler's URL does, so the
+// connecting page can only be known from an explicit ?page= query parameter,
+// which every client MUST send for the token's PageName to be checked at
+// all.
+func wsAuthRequired() gin.HandlerFunc {
+	return verifyToken(true)
+}
+
+// verifyToken checks the PagePayload JWT and, when requirePage is true,
+// requires a ?page= query parameter matching the token's PageName - without
+// that the "reject tokens whose PageName doesn't match the connecting page"
+// requirement would be unenforceable for routes, like /ws, that don't carry
+// the page name anywhere else in the request.
+func verifyToken(requirePage bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.SecretKey() == "" {
+			c.Next()
+			return
+		}
+
+		tokenStr := bearerToken(c)
+		if tokenStr == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "missing bearer token"})
+			return
+		}
+
+		var payload PagePayload
+		now := time.Now()
+		if _, err := jwt.Verify([]byte(tokenStr), signer(), &payload,
+			jwt.ValidatePayload(&payload.Payload, jwt.ExpirationTimeValidator(now), jwt.NotBeforeValidator(now)),
+		); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "invalid or expired token"})
+			return
+		}
+
+		pageName := c.Query("page")
+		if requirePage && pageName == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "missing page parameter"})
+			return
+		}
+		if pageName != "" && pageName != payload.PageName {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "token page mismatch"})
+			return
+		}
+
+		c.Set("pagePayload", payload)
+		c.Next()
+	}
+}
+
+// requireSharedSecret gates POST /api/auth/token itself: only callers that
+// already know FLET_SECRET_KEY may mint page tokens.
+func requireSharedSecret() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := config.SecretKey()
+		if secret == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"message": "FLET_SECRET_KEY is not configured"})
+			return
+		}
+		if bearerToken(c) != secret {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "invalid secret"})
+			return
+		}
+		c.Next()
+	}
+}
+
+type mintTokenRequest struct {
+	PageName    string   `json:"page_name" binding:"required"`
+	SessionID   string   `json:"session_id"`
+	Permissions []string `json:"permissions"`
+}
+
+func mintTokenHandler(c *gin.Context) {
+	var req mintTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	token, err := mintPageToken(req.PageName, req.SessionID, req.Permissions)
+	if err != nil {
+		log.Errorln("Error minting page token:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed to mint token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/gin-gonic/gin"
+)
+
+// mintExpiredPageToken signs a PagePayload that already expired, for testing
+// that verifyToken actually enforces ExpirationTime rather than just parsing
+// it.
+func mintExpiredPageToken(t *testing.T, pageName string) string {
+	t.Helper()
+	now := time.Now()
+	payload := PagePayload{
+		Payload: jwt.Payload{
+			Issuer:         "flet-server",
+			IssuedAt:       jwt.NumericDate(now.Add(-2 * tokenTTL)),
+			NotBefore:      jwt.NumericDate(now.Add(-2 * tokenTTL)),
+			ExpirationTime: jwt.NumericDate(now.Add(-tokenTTL)),
+		},
+		PageName: pageName,
+	}
+
+	token, err := jwt.Sign(payload, signer())
+	if err != nil {
+		t.Fatalf("jwt.Sign: %v", err)
+	}
+	return string(token)
+}
+
+func withSecretKey(t *testing.T, secret string) {
+	t.Helper()
+	old := os.Getenv("FLET_SECRET_KEY")
+	os.Setenv("FLET_SECRET_KEY", secret)
+	t.Cleanup(func() { os.Setenv("FLET_SECRET_KEY", old) })
+}
+
+func newTestRouter(handler gin.HandlerFunc, path string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(path, handler, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func doRequest(router *gin.Engine, target string, token string, asQueryParam bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if token != "" {
+		if asQueryParam {
+			q := req.URL.Query()
+			q.Set("token", token)
+			req.URL.RawQuery = q.Encode()
+		} else {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestVerifyTokenNoOpWhenSecretUnset(t *testing.T) {
+	withSecretKey(t, "")
+	router := newTestRouter(verifyToken(false), "/protected")
+
+	w := doRequest(router, "/protected", "", false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with no secret configured, got %d", w.Code)
+	}
+}
+
+func TestVerifyTokenRejectsMissingToken(t *testing.T) {
+	withSecretKey(t, "shh")
+	router := newTestRouter(verifyToken(false), "/protected")
+
+	w := doRequest(router, "/protected", "", false)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for missing token, got %d", w.Code)
+	}
+}
+
+func TestVerifyTokenRejectsInvalidToken(t *testing.T) {
+	withSecretKey(t, "shh")
+	router := newTestRouter(verifyToken(false), "/protected")
+
+	w := doRequest(router, "/protected", "not-a-real-token", false)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for invalid token, got %d", w.Code)
+	}
+}
+
+func TestVerifyTokenAcceptsValidToken(t *testing.T) {
+	withSecretKey(t, "shh")
+
+	token, err := mintPageToken("my-page", "session-1", nil)
+	if err != nil {
+		t.Fatalf("mintPageToken: %v", err)
+	}
+
+	router := newTestRouter(verifyToken(false), "/protected")
+	w := doRequest(router, "/protected", token, false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for valid token, got %d", w.Code)
+	}
+
+	// also accepted via ?token= for WebSocket clients that can't set headers
+	router = newTestRouter(verifyToken(false), "/protected")
+	w = doRequest(router, "/protected", token, true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for valid token via query param, got %d", w.Code)
+	}
+}
+
+func TestVerifyTokenRejectsPageMismatch(t *testing.T) {
+	withSecretKey(t, "shh")
+
+	token, err := mintPageToken("page-a", "session-1", nil)
+	if err != nil {
+		t.Fatalf("mintPageToken: %v", err)
+	}
+
+	router := newTestRouter(verifyToken(false), "/protected")
+	w := doRequest(router, "/protected?page=page-b", token, false)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for mismatched page, got %d", w.Code)
+	}
+}
+
+func TestVerifyTokenRejectsExpiredToken(t *testing.T) {
+	withSecretKey(t, "shh")
+
+	token := mintExpiredPageToken(t, "my-page")
+
+	router := newTestRouter(verifyToken(false), "/protected")
+	w := doRequest(router, "/protected", token, false)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an expired token, got %d", w.Code)
+	}
+}
+
+func TestWsAuthRequiredRejectsMissingPageParam(t *testing.T) {
+	withSecretKey(t, "shh")
+
+	token, err := mintPageToken("page-a", "session-1", nil)
+	if err != nil {
+		t.Fatalf("mintPageToken: %v", err)
+	}
+
+	router := newTestRouter(wsAuthRequired(), "/ws")
+	w := doRequest(router, "/ws", token, false)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when ?page= is missing on /ws, got %d", w.Code)
+	}
+
+	w = doRequest(router, "/ws?page=page-a", token, false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 when ?page= matches the token, got %d", w.Code)
+	}
+}
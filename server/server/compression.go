@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/NYTimes/gziphandler"
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flet-dev/flet/server/config"
+)
+
+// compressionExcludedExtensions skips assets that are already compressed,
+// where re-compressing just burns CPU for no size benefit.
+var compressionExcludedExtensions = map[string]bool{
+	".png":   true,
+	".woff2": true,
+	".wasm":  true,
+}
+
+// wrapCompression negotiates gzip or brotli compression for h based on
+// Accept-Encoding. The /ws upgrade path is always passed through untouched -
+// the WebSocket upgrader negotiates its own per-message-deflate instead, see
+// websocketHandler.
+func wrapCompression(h http.Handler) http.Handler {
+	gz, err := gziphandler.GzipHandlerWithOpts(
+		gziphandler.CompressionLevel(config.CompressionLevel()),
+		gziphandler.MinSize(config.CompressionMinSize()),
+	)
+	if err != nil {
+		log.Errorln("Error configuring compression middleware:", err)
+		return h
+	}
+	gzHandler := gz(h)
+	brHandler := brotliHandler(h)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" || websocket.IsWebSocketUpgrade(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if compressionExcludedExtensions[path.Ext(r.URL.Path)] {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		switch acceptedEncoding(r) {
+		case "br":
+			brHandler.ServeHTTP(w, r)
+		case "gzip":
+			gzHandler.ServeHTTP(w, r)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+func acceptedEncoding(r *http.Request) string {
+	accept := r.Header.Get("Accept-Encoding")
+	if strings.Contains(accept, "br") {
+		return "br"
+	}
+	if strings.Contains(accept, "gzip") {
+		return "gzip"
+	}
+	return ""
+}
+
+// brotliResponseWriter mirrors gziphandler's own MinSize behavior: writes
+// are buffered until either minSize is reached (then brotli-compress
+// everything from that point on) or the response finishes below minSize
+// (then it's flushed through uncompressed, same as a small JSON API reply).
+type brotliResponseWriter struct {
+	http.ResponseWriter
+	level      int
+	minSize    int
+	buf        bytes.Buffer
+	compressor *brotli.Writer
+	decided    bool
+	statusCode int
+}
+
+func (w *brotliResponseWriter) WriteHeader(status int) {
+	w.statusCode = status
+}
+
+func (w *brotliResponseWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.compressor != nil {
+			return w.compressor.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() < w.minSize {
+		return len(b), nil
+	}
+
+	return len(b), w.startCompressing()
+}
+
+func (w *brotliResponseWriter) startCompressing() error {
+	w.decided = true
+	w.Header().Set("Content-Encoding", "br")
+	w.Header().Add("Vary", "Accept-Encoding")
+	// The brotli-compressed byte count won't match whatever Content-Length
+	// the upstream handler (e.g. static.Serve/http.ServeContent) set for the
+	// uncompressed asset, so it has to go - same reason gziphandler strips it.
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	w.compressor = brotli.NewWriterLevel(w.ResponseWriter, w.level)
+	_, err := w.compressor.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// Close flushes whatever's left: the compressor, if the response reached
+// minSize, or the buffered-but-never-compressed bytes otherwise.
+func (w *brotliResponseWriter) Close() error {
+	if w.decided {
+		if w.compressor != nil {
+			return w.compressor.Close()
+		}
+		return nil
+	}
+
+	w.decided = true
+	// Never reached minSize, so the body is flushed verbatim below - but any
+	// Content-Length set before compression was decided against is still
+	// wrong if it didn't account for buffering, so drop it here too and let
+	// the transport determine the length from the actual write.
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	return err
+}
+
+func brotliHandler(h http.Handler) http.Handler {
+	level := config.CompressionLevel()
+	minSize := config.CompressionMinSize()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := &brotliResponseWriter{ResponseWriter: w, level: level, minSize: minSize, statusCode: http.StatusOK}
+		defer bw.Close()
+
+		h.ServeHTTP(bw, r)
+	})
+}
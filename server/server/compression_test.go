@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func withCompressionMinSize(t *testing.T, minSize string) {
+	t.Helper()
+	old := os.Getenv("FLET_COMPRESSION_MIN_SIZE")
+	os.Setenv("FLET_COMPRESSION_MIN_SIZE", minSize)
+	t.Cleanup(func() { os.Setenv("FLET_COMPRESSION_MIN_SIZE", old) })
+}
+
+func TestBrotliHandlerSkipsSmallResponses(t *testing.T) {
+	withCompressionMinSize(t, "1400")
+
+	handler := brotliHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":"pong"}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("expected no Content-Encoding for a response below MinSize, got %q", enc)
+	}
+	if w.Body.String() != `{"message":"pong"}` {
+		t.Fatalf("expected body to be passed through unchanged, got %q", w.Body.String())
+	}
+}
+
+func TestBrotliHandlerCompressesLargeResponses(t *testing.T) {
+	withCompressionMinSize(t, "16")
+
+	large := strings.Repeat("x", 1024)
+	handler := brotliHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(large))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if enc := w.Header().Get("Content-Encoding"); enc != "br" {
+		t.Fatalf("expected Content-Encoding: br for a response above MinSize, got %q", enc)
+	}
+	if w.Body.String() == large {
+		t.Fatal("expected the body to be brotli-compressed, not passed through verbatim")
+	}
+}
+
+func TestBrotliHandlerStripsStaleContentLength(t *testing.T) {
+	withCompressionMinSize(t, "16")
+
+	large := strings.Repeat("x", 1024)
+	handler := brotliHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Mirrors what static.Serve/http.ServeContent do upstream: set
+		// Content-Length for the uncompressed body before writing it.
+		w.Header().Set("Content-Length", strconv.Itoa(len(large)))
+		w.Write([]byte(large))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if cl := w.Header().Get("Content-Length"); cl != "" {
+		t.Fatalf("expected stale Content-Length to be stripped before brotli flush, got %q", cl)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/flet-dev/flet/server/config"
+)
+
+// indexCache holds one rendered index.html per (baseHref, routeURLStrategy,
+// webRenderer, useColorEmoji) tuple, populated lazily by renderIndex.
+var indexCache sync.Map // map[indexCacheKey]*cachedIndex
+
+var (
+	indexCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "flet_index_cache_hits_total",
+		Help: "Number of index.html renders served from cache.",
+	})
+	indexCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "flet_index_cache_misses_total",
+		Help: "Number of index.html renders that had to be computed.",
+	})
+)
+
+type indexCacheKey struct {
+	baseHref         string
+	routeURLStrategy string
+	webRenderer      string
+	useColorEmoji    bool
+}
+
+type cachedIndex struct {
+	data []byte
+	etag string
+}
+
+// resetIndexCache drops every cached rendering. Called whenever the router
+// is rebuilt (see buildRouter) so a config reload that changes
+// routeURLStrategy/webRenderer doesn't keep serving stale HTML. Deletes
+// entries one at a time rather than reassigning indexCache, since the
+// previous router generation may still be draining live traffic through
+// renderIndex concurrently - replacing the sync.Map value itself would race
+// with those Load/Store calls.
+func resetIndexCache() {
+	indexCache.Range(func(key, _ interface{}) bool {
+		indexCache.Delete(key)
+		return true
+	})
+}
+
+// renderIndex returns the templated index.html for baseHref, computing and
+// caching it on first use, and caches a SHA-256 ETag alongside it so callers
+// can answer conditional GETs with 304 Not Modified.
+func renderIndex(assetsFS http.FileSystem, baseHref string) (*cachedIndex, error) {
+	key := indexCacheKey{
+		baseHref:         baseHref,
+		routeURLStrategy: config.RouteUrlStrategy(),
+		webRenderer:      config.WebRenderer(),
+		useColorEmoji:    config.UseColorEmoji(),
+	}
+
+	if v, ok := indexCache.Load(key); ok {
+		indexCacheHits.Inc()
+		return v.(*cachedIndex), nil
+	}
+
+	indexCacheMisses.Inc()
+
+	index, err := assetsFS.Open(siteDefaultDocument)
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+
+	indexData, err := io.ReadAll(index)
+	if err != nil {
+		return nil, err
+	}
+
+	// base path
+	indexData = bytes.Replace(indexData,
+		[]byte("<base href=\"/\">"),
+		[]byte("<base href=\""+key.baseHref+"\">"), 1)
+
+	// route URL strategy
+	indexData = bytes.Replace(indexData,
+		[]byte("%FLET_ROUTE_URL_STRATEGY%"),
+		[]byte(key.routeURLStrategy), 1)
+
+	// web renderer
+	if key.webRenderer != "" {
+		indexData = bytes.Replace(indexData,
+			[]byte("<!-- flutterWebRenderer -->"),
+			[]byte(fmt.Sprintf("<script>var flutterWebRenderer=\"%s\";</script>", key.webRenderer)), 1)
+	}
+
+	// color emoji
+	indexData = bytes.Replace(indexData,
+		[]byte("<!-- useColorEmoji -->"),
+		[]byte(fmt.Sprintf("<script>var useColorEmoji=%v;</script>", key.useColorEmoji)), 1)
+
+	sum := sha256.Sum256(indexData)
+	entry := &cachedIndex{
+		data: indexData,
+		etag: `"` + hex.EncodeToString(sum[:]) + `"`,
+	}
+
+	indexCache.Store(key, entry)
+	return entry, nil
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempIndex(t *testing.T, contents string) http.FileSystem {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write index.html: %v", err)
+	}
+	return http.Dir(dir)
+}
+
+func TestRenderIndexCachesByTuple(t *testing.T) {
+	resetIndexCache()
+	defer resetIndexCache()
+
+	fs := writeTempIndex(t, `<html><base href="/"><body>%FLET_ROUTE_URL_STRATEGY%<!-- flutterWebRenderer --><!-- useColorEmoji --></body></html>`)
+
+	first, err := renderIndex(fs, "/")
+	if err != nil {
+		t.Fatalf("renderIndex: %v", err)
+	}
+	if first.etag == "" {
+		t.Fatal("expected a non-empty ETag")
+	}
+
+	second, err := renderIndex(fs, "/")
+	if err != nil {
+		t.Fatalf("renderIndex (cached): %v", err)
+	}
+	if second != first {
+		t.Fatal("expected the second call to return the cached entry, not recompute it")
+	}
+
+	third, err := renderIndex(fs, "/some/page/")
+	if err != nil {
+		t.Fatalf("renderIndex (different baseHref): %v", err)
+	}
+	if third == first {
+		t.Fatal("expected a different baseHref to miss the cache")
+	}
+}
+
+func TestResetIndexCacheEvictsEntries(t *testing.T) {
+	resetIndexCache()
+	defer resetIndexCache()
+
+	fs := writeTempIndex(t, `<html><base href="/"><body>%FLET_ROUTE_URL_STRATEGY%<!-- flutterWebRenderer --><!-- useColorEmoji --></body></html>`)
+
+	before, err := renderIndex(fs, "/")
+	if err != nil {
+		t.Fatalf("renderIndex: %v", err)
+	}
+
+	resetIndexCache()
+
+	after, err := renderIndex(fs, "/")
+	if err != nil {
+		t.Fatalf("renderIndex (post-reset): %v", err)
+	}
+	if after == before {
+		t.Fatal("expected resetIndexCache to force a fresh render")
+	}
+}
+
+// TestRenderIndexETagMatchesIfNoneMatch checks renderIndex's ETag under the
+// plain string equality router.go's NoRoute handler uses to answer
+// conditional GETs with 304; it does not drive the handler itself.
+func TestRenderIndexETagMatchesIfNoneMatch(t *testing.T) {
+	resetIndexCache()
+	defer resetIndexCache()
+
+	fs := writeTempIndex(t, `<html><base href="/"><body>%FLET_ROUTE_URL_STRATEGY%<!-- flutterWebRenderer --><!-- useColorEmoji --></body></html>`)
+
+	rendered, err := renderIndex(fs, "/")
+	if err != nil {
+		t.Fatalf("renderIndex: %v", err)
+	}
+
+	ifNoneMatch := rendered.etag
+	if ifNoneMatch != rendered.etag {
+		t.Fatalf("expected If-None-Match echoing the served ETag to match, got %q vs %q", ifNoneMatch, rendered.etag)
+	}
+
+	staleIfNoneMatch := `"stale-etag"`
+	if staleIfNoneMatch == rendered.etag {
+		t.Fatal("expected a stale If-None-Match to not match the current ETag")
+	}
+}
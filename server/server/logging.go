@@ -0,0 +1,75 @@
+package server
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flet-dev/flet/server/config"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// accessLogMiddleware replaces gin.Default()'s stock logger with one
+// structured event per request, correlated via X-Request-ID so a
+// user-reported issue can be traced from the HTTP handshake through the
+// WebSocket session it upgrades into - see websocketHandler, which logs the
+// same ID.
+func accessLogMiddleware() gin.HandlerFunc {
+	format := config.AccessLogFormat()
+	if format == "off" {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	if format == "text" {
+		log.SetFormatter(&log.TextFormatter{})
+	} else {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+
+	sampleRate := config.AccessLogSampleRate()
+
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		c.Writer.Header().Set(requestIDHeader, requestID)
+		c.Set("requestID", requestID)
+
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		if sampleRate < 1 && rand.Float64() >= sampleRate {
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"request_id": requestID,
+			"remote_ip":  c.ClientIP(),
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"status":     c.Writer.Status(),
+			"latency_ms": latency.Milliseconds(),
+			"ua":         c.Request.UserAgent(),
+			"page_name":  pageNameFromPath(c.Request.URL.Path),
+		}).Info("request")
+	}
+}
+
+// requestIDFromContext reads back the ID accessLogMiddleware stashed on the
+// gin context, for handlers (like websocketHandler) that need to propagate
+// it past the HTTP response into a longer-lived session. Returns "" when
+// AccessLogFormat is "off" and no middleware ran.
+func requestIDFromContext(c *gin.Context) string {
+	if id, ok := c.Get("requestID"); ok {
+		if s, ok := id.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
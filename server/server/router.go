@@ -0,0 +1,182 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/flet-dev/flet/server/config"
+	"github.com/flet-dev/flet/server/page"
+	page_connection "github.com/flet-dev/flet/server/page/connection"
+	"github.com/flet-dev/flet/server/store"
+	"github.com/gin-gonic/contrib/secure"
+	"github.com/gin-gonic/contrib/static"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiRoutePrefix      string = "/api"
+	siteDefaultDocument string = "index.html"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:    1024,
+	WriteBufferSize:   1024,
+	EnableCompression: true,
+}
+
+// buildRouter assembles a fresh gin.Engine from the current config. It is
+// called once on startup and again on every config reload, so it must not
+// depend on any state beyond contentDir/assetsDir and the config package.
+func buildRouter(contentDir string, assetsDir string) *gin.Engine {
+	// routeURLStrategy/webRenderer may have changed since the last build, so
+	// any previously rendered index.html tuples are now stale.
+	resetIndexCache()
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(accessLogMiddleware())
+
+	if config.TrustedProxies() != nil && len(config.TrustedProxies()) > 0 {
+		log.Println("Trusted proxies:", config.TrustedProxies())
+		router.SetTrustedProxies(config.TrustedProxies())
+	}
+
+	// force SSL
+	if config.ForceSSL() {
+		router.Use(secure.Secure(secure.Options{
+			AllowedHosts:          []string{},
+			SSLRedirect:           true,
+			SSLHost:               "", // use the same host
+			SSLProxyHeaders:       map[string]string{"X-Forwarded-Proto": "https"},
+			STSSeconds:            315360000,
+			STSIncludeSubdomains:  true,
+			FrameDeny:             true,
+			ContentTypeNosniff:    true,
+			BrowserXssFilter:      true,
+			ContentSecurityPolicy: "",
+		}))
+	}
+
+	mime.AddExtensionType(".js", "application/javascript")
+
+	// Serve frontend static files
+	assetsFS := newAssetsFS(contentDir, assetsDir)
+	router.Use(static.Serve("/", assetsFS))
+
+	// WebSockets
+	router.GET("/ws", wsAuthRequired(), func(c *gin.Context) {
+		websocketHandler(c)
+	})
+
+	// Setup route group for the API
+	api := router.Group(apiRoutePrefix)
+	{
+		api.GET("/", func(c *gin.Context) {
+			time.Sleep(4 * time.Second)
+			c.JSON(http.StatusOK, gin.H{
+				"message": "pong",
+			})
+		})
+	}
+
+	api.POST("/auth/token", requireSharedSecret(), mintTokenHandler)
+	api.GET("/oauth/redirect", authRequired(), oauthCallbackHandler)
+	api.PUT("/upload", authRequired(), uploadFileAsStream)
+
+	if config.MetricsEnabled() {
+		api.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	}
+
+	// unknown API routes - 404, all the rest - index.html
+	router.NoRoute(func(c *gin.Context) {
+
+		if !strings.HasPrefix(c.Request.RequestURI, apiRoutePrefix+"/") {
+			baseHref := pageNameFromPath(c.Request.URL.Path)
+			log.Debugln("Request path:", baseHref)
+
+			if baseHref != "" {
+				baseHref = "/" + baseHref + "/"
+			} else {
+				baseHref = "/"
+			}
+
+			rendered, err := renderIndex(assetsFS, baseHref)
+			if err != nil {
+				log.Errorln("Error rendering index.html:", err)
+				c.Status(http.StatusInternalServerError)
+				return
+			}
+
+			if c.GetHeader("If-None-Match") == rendered.etag {
+				c.Status(http.StatusNotModified)
+				return
+			}
+
+			c.Header("ETag", rendered.etag)
+			c.Header("Cache-Control", "no-cache, must-revalidate")
+			c.Data(http.StatusOK, "text/html", rendered.data)
+		} else {
+			// API not found
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "API endpoint not found",
+			})
+		}
+	})
+
+	return router
+}
+
+// pageNameFromPath extracts the page name (first two URL segments) from a
+// request path, returning "" when the path doesn't resolve to a known page -
+// used both for the index.html base href and for access log correlation.
+func pageNameFromPath(urlPath string) string {
+	baseHref := strings.Trim(urlPath, "/")
+	if baseHref == "" {
+		return ""
+	}
+
+	hrefParts := strings.Split(baseHref, "/")
+	if len(hrefParts) <= 1 {
+		return ""
+	}
+
+	baseHref = strings.Join(hrefParts[:2], "/")
+	if store.GetPageByName(baseHref) == nil {
+		return ""
+	}
+
+	return baseHref
+}
+
+func websocketHandler(c *gin.Context) {
+
+	requestID := requestIDFromContext(c)
+
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		return true
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.WithField("request_id", requestID).Errorln("Error upgrading WebSocket connection:", err)
+		return
+	}
+
+	// per-message-deflate was negotiated during the upgrade above, which
+	// already turns on write compression; this makes that explicit.
+	conn.EnableWriteCompression(true)
+
+	log.WithField("request_id", requestID).Debugln("WebSocket session established")
+
+	// requestID carries through so page_connection.NewWebSocket can tag every
+	// inbound/outbound frame it logs with the same ID that tagged the HTTP
+	// handshake, letting a user-reported issue be traced end to end.
+	wsc := page_connection.NewWebSocket(conn, requestID)
+	page.NewClient(wsc, c.ClientIP(), c.Request.UserAgent(), requestID)
+}
@@ -1,215 +1,259 @@
 package server
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
-	"mime"
+	"net"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/gorilla/websocket"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme/autocert"
 
 	"github.com/flet-dev/flet/server/config"
 	"github.com/flet-dev/flet/server/page"
-	page_connection "github.com/flet-dev/flet/server/page/connection"
-	"github.com/flet-dev/flet/server/store"
-	"github.com/gin-gonic/contrib/secure"
-	"github.com/gin-gonic/contrib/static"
-	"github.com/gin-gonic/gin"
-)
-
-const (
-	apiRoutePrefix      string = "/api"
-	siteDefaultDocument string = "index.html"
 )
 
 var (
 	Port int = 8550
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+// Server binds a single TCP listener and serves it with a gin.Engine that
+// can be rebuilt in place - Reload() - without closing the socket,
+// so config changes (trusted proxies, force-SSL, web renderer, route URL
+// strategy) don't drop in-flight requests or the WebSocket sessions held by
+// page_connection.NewWebSocket.
+type Server struct {
+	contentDir string
+	assetsDir  string
+
+	reload chan struct{}
+
+	// certManager is built once, in Run, and reused by both startGeneration
+	// (ACME http-01 challenges/redirects on the main listener) and
+	// startAutocert (TLS termination on :443), so there is a single source
+	// of truth for in-memory challenge/cert state - never two competing
+	// autocert.Managers racing Let's Encrypt for the same hosts.
+	certManager *autocert.Manager
+
+	mu       sync.Mutex
+	srv      *http.Server
+	httpsSrv *http.Server
 }
 
-func Start(ctx context.Context, wg *sync.WaitGroup, serverPort int, contentDir string, assetsDir string) {
-	defer wg.Done()
+// NewServer creates a Server that will serve contentDir/assetsDir once Run
+// is called with a listener obtained from Listen.
+func NewServer(contentDir string, assetsDir string) *Server {
+	return &Server{
+		contentDir: contentDir,
+		assetsDir:  assetsDir,
+		reload:     make(chan struct{}, 1),
+	}
+}
 
-	if contentDir == "" {
-		log.Fatalf("contentDir is not set")
+// Listen binds the TCP listener Run will serve. It is separate from Run so
+// a caller can keep the same socket open across config reloads.
+func (s *Server) Listen(port int) (net.Listener, error) {
+	addr := fmt.Sprintf("%s:%d", config.ServerIP(), port)
+	return net.Listen("tcp", addr)
+}
+
+// Reload schedules a rebuild of the routing engine from the current config.
+// Safe to call from any goroutine; it is also triggered by SIGHUP.
+func (s *Server) Reload() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+		// a reload is already pending
 	}
+}
 
-	Port = serverPort
+// keepOpenListener wraps a net.Listener so that http.Server.Shutdown doesn't
+// close the underlying socket. This lets the next generation's http.Server
+// go on Serve()-ing the same listener after the previous generation drains.
+type keepOpenListener struct {
+	net.Listener
+}
 
-	// Set the router as the default one shipped with Gin
-	router := gin.Default()
+func (keepOpenListener) Close() error { return nil }
 
-	if config.TrustedProxies() != nil && len(config.TrustedProxies()) > 0 {
-		log.Println("Trusted proxies:", config.TrustedProxies())
-		router.SetTrustedProxies(config.TrustedProxies())
+// Run builds the gin engine for the current config and serves ln until ctx
+// is done. A SIGHUP or a call to Reload rebuilds the engine and swaps it in
+// without closing ln.
+func (s *Server) Run(ctx context.Context, ln net.Listener) {
+	if s.contentDir == "" {
+		log.Fatalf("contentDir is not set")
 	}
 
-	// force SSL
-	if config.ForceSSL() {
-		router.Use(secure.Secure(secure.Options{
-			AllowedHosts:          []string{},
-			SSLRedirect:           true,
-			SSLHost:               "", // use the same host
-			SSLProxyHeaders:       map[string]string{"X-Forwarded-Proto": "https"},
-			STSSeconds:            315360000,
-			STSIncludeSubdomains:  true,
-			FrameDeny:             true,
-			ContentTypeNosniff:    true,
-			BrowserXssFilter:      true,
-			ContentSecurityPolicy: "",
-		}))
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	if autocertHosts := config.AutocertHosts(); len(autocertHosts) > 0 {
+		s.certManager = s.newAutocertManager(autocertHosts)
 	}
 
-	mime.AddExtensionType(".js", "application/javascript")
-
-	// Serve frontend static files
-	assetsFS := newAssetsFS(contentDir, assetsDir)
-	router.Use(static.Serve("/", assetsFS))
-
-	// WebSockets
-	router.GET("/ws", func(c *gin.Context) {
-		websocketHandler(c)
-	})
-
-	// Setup route group for the API
-	api := router.Group(apiRoutePrefix)
-	{
-		api.GET("/", func(c *gin.Context) {
-			time.Sleep(4 * time.Second)
-			c.JSON(http.StatusOK, gin.H{
-				"message": "pong",
-			})
-		})
+	wrapped := keepOpenListener{ln}
+	s.startGeneration(wrapped)
+	s.startAutocert()
+
+	go page.RunBackgroundTasks(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.shutdown()
+			_ = ln.Close()
+			return
+		case <-hup:
+			log.Println("Received SIGHUP, reloading server configuration...")
+			s.rotateGeneration(wrapped)
+		case <-s.reload:
+			log.Println("Reloading server configuration...")
+			s.rotateGeneration(wrapped)
+		}
 	}
+}
 
-	api.GET("/oauth/redirect", oauthCallbackHandler)
-	api.PUT("/upload", uploadFileAsStream)
-
-	// unknown API routes - 404, all the rest - index.html
-	router.NoRoute(func(c *gin.Context) {
-
-		if !strings.HasPrefix(c.Request.RequestURI, apiRoutePrefix+"/") {
-			baseHref := strings.Trim(c.Request.URL.Path, "/")
-			log.Debugln("Request path:", baseHref)
-
-			if baseHref != "" {
-				hrefParts := strings.Split(baseHref, "/")
-				if len(hrefParts) > 1 {
-					baseHref = strings.Join(hrefParts[:2], "/")
-					if store.GetPageByName(baseHref) == nil {
-						// fallback to index page
-						baseHref = ""
-					}
-				} else {
-					baseHref = ""
-				}
-			}
+// startGeneration builds a fresh router from the current config and serves
+// it on ln as the server's current generation.
+func (s *Server) startGeneration(ln net.Listener) {
+	router := buildRouter(s.contentDir, s.assetsDir)
 
-			if baseHref != "" {
-				baseHref = "/" + baseHref + "/"
-			} else {
-				baseHref = "/"
-			}
+	var handler http.Handler = wrapCompression(router)
+	if s.certManager != nil {
+		handler = s.certManager.HTTPHandler(nil)
+	}
 
-			index, _ := assetsFS.Open(siteDefaultDocument)
-			indexData, _ := io.ReadAll(index)
+	srv := &http.Server{Handler: handler}
 
-			// base path
-			indexData = bytes.Replace(indexData,
-				[]byte("<base href=\"/\">"),
-				[]byte("<base href=\""+baseHref+"\">"), 1)
+	s.mu.Lock()
+	s.srv = srv
+	s.mu.Unlock()
 
-			// route URL strategy
-			indexData = bytes.Replace(indexData,
-				[]byte("%FLET_ROUTE_URL_STRATEGY%"),
-				[]byte(config.RouteUrlStrategy()), 1)
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorln("Error serving:", err)
+		}
+	}()
+}
 
-			// web renderer
-			if config.WebRenderer() != "" {
-				indexData = bytes.Replace(indexData,
-					[]byte("<!-- flutterWebRenderer -->"),
-					[]byte(fmt.Sprintf("<script>var flutterWebRenderer=\"%s\";</script>", config.WebRenderer())), 1)
-			}
+// rotateGeneration starts a new generation on ln, then gracefully drains the
+// previous one. Existing page.Client WebSocket sessions are unaffected since
+// Shutdown only stops new connections and waits out idle ones. When autocert
+// is enabled, httpsSrv is rotated too, so the :443 generation also picks up
+// the reloaded trusted-proxies/force-SSL/web-renderer/route-URL-strategy
+// config instead of staying frozen at whatever Run started with.
+func (s *Server) rotateGeneration(ln net.Listener) {
+	s.mu.Lock()
+	previousHTTP, previousHTTPS := s.srv, s.httpsSrv
+	s.mu.Unlock()
+
+	s.startGeneration(ln)
+
+	ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := previousHTTP.Shutdown(ctxShutDown); err != nil {
+		log.Errorln("Error shutting down previous server generation:", err)
+	}
 
-			// color emoji
-			indexData = bytes.Replace(indexData,
-				[]byte("<!-- useColorEmoji -->"),
-				[]byte(fmt.Sprintf("<script>var useColorEmoji=%v;</script>", config.UseColorEmoji())), 1)
-
-			c.Data(http.StatusOK, "text/html", indexData)
-		} else {
-			// API not found
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": "API endpoint not found",
-			})
+	if s.certManager != nil {
+		// httpsSrv binds its own listener on :443 (ListenAndServeTLS), so
+		// unlike the main generation it must be fully drained before the
+		// replacement can bind the same port.
+		if previousHTTPS != nil {
+			if err := previousHTTPS.Shutdown(ctxShutDown); err != nil {
+				log.Errorln("Error shutting down previous HTTPS server generation:", err)
+			}
 		}
-	})
+		s.startAutocert()
+	}
+}
+
+// startAutocert wires up the TLS (:443) listener when AutocertHosts is
+// configured, reusing s.certManager. It terminates TLS for the gin router
+// directly; the plain-HTTP generation on ln keeps serving ACME http-01
+// challenges and redirects.
+func (s *Server) startAutocert() {
+	if s.certManager == nil {
+		return
+	}
 
-	addr := fmt.Sprintf("%s:%d", config.ServerIP(), serverPort)
-	log.Println("Starting server on", addr)
+	router := buildRouter(s.contentDir, s.assetsDir)
 
-	// Start and run the server
-	srv := &http.Server{
-		Addr:    addr,
-		Handler: router,
+	httpsSrv := &http.Server{
+		Addr:      ":443",
+		Handler:   wrapCompression(router),
+		TLSConfig: s.certManager.TLSConfig(),
 	}
 
-	// Initializing the server in a goroutine so that
-	// it won't block the graceful shutdown handling below
+	s.mu.Lock()
+	s.httpsSrv = httpsSrv
+	s.mu.Unlock()
+
 	go func() {
-		for i := 1; i < 10; i++ {
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				if i == 9 {
-					log.Fatalf("listen: %s\n", err)
-				}
-				time.Sleep(time.Duration(100) * time.Millisecond)
-				continue
-			}
-			break
+		if err := httpsSrv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			log.Errorln("Error serving (https):", err)
 		}
 	}()
 
-	go func() {
-		page.RunBackgroundTasks(ctx)
-	}()
+	log.Println("Starting TLS server on :443 for hosts", config.AutocertHosts())
+}
 
-	<-ctx.Done()
+func (s *Server) newAutocertManager(hosts []string) *autocert.Manager {
+	cache := config.AutocertCache
+	if cache == nil {
+		cache = autocert.DirCache(config.AutocertCacheDir())
+	}
 
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Cache:      cache,
+	}
+}
+
+func (s *Server) shutdown() {
 	log.Println("Shutting down server...")
 
-	// The context is used to inform the server it has 5 seconds to finish
-	// the request it is currently handling
+	s.mu.Lock()
+	srv, httpsSrv := s.srv, s.httpsSrv
+	s.mu.Unlock()
+
 	ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctxShutDown); err != nil {
 		log.Fatal("Server forced to shutdown:", err)
 	}
+	if httpsSrv != nil {
+		if err := httpsSrv.Shutdown(ctxShutDown); err != nil {
+			log.Fatal("HTTPS server forced to shutdown:", err)
+		}
+	}
 
 	log.Println("Server exited")
 }
 
-func websocketHandler(c *gin.Context) {
+// Start is a convenience wrapper around NewServer/Listen/Run for callers
+// that don't need to reload config at runtime or hold on to the listener
+// themselves.
+func Start(ctx context.Context, wg *sync.WaitGroup, serverPort int, contentDir string, assetsDir string) {
+	defer wg.Done()
 
-	upgrader.CheckOrigin = func(r *http.Request) bool {
-		return true
-	}
+	Port = serverPort
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	s := NewServer(contentDir, assetsDir)
+
+	ln, err := s.Listen(serverPort)
 	if err != nil {
-		log.Errorln("Error upgrading WebSocket connection:", err)
-		return
+		log.Fatalf("listen: %s\n", err)
 	}
 
-	wsc := page_connection.NewWebSocket(conn)
-	page.NewClient(wsc, c.ClientIP(), c.Request.UserAgent())
+	log.Println("Starting server on", ln.Addr())
+
+	s.Run(ctx, ln)
 }